@@ -0,0 +1,95 @@
+// Package metrics exposes Prometheus instrumentation for the parser and
+// client proxy, so operators can alert on indexing lag and RPC health
+// instead of relying solely on zerolog messages.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "juno"
+
+var (
+	// BlocksEnqueued counts how many block heights have been pushed onto the
+	// events queue, regardless of source (missing-block scan, new block
+	// listener or flush routine).
+	BlocksEnqueued = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "blocks_enqueued_total",
+		Help:      "Total number of block heights enqueued for parsing",
+	})
+
+	// BlocksProcessed counts how many block heights a worker has finished
+	// parsing, labeled by outcome.
+	BlocksProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "blocks_processed_total",
+		Help:      "Total number of blocks processed by workers",
+	}, []string{"status"})
+
+	// WorkerProcessingDuration observes how long a worker took to process a
+	// single block, in seconds.
+	WorkerProcessingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "worker_processing_duration_seconds",
+		Help:      "Time taken by a worker to process a single block",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"worker"})
+
+	// LCDRequestDuration observes the latency of outgoing LCD requests
+	// (QueryLCD, Tx), labeled by endpoint.
+	LCDRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "lcd_request_duration_seconds",
+		Help:      "Latency of LCD requests made through ClientProxy",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint"})
+
+	// LCDRequestErrors counts failed LCD requests, labeled by endpoint.
+	LCDRequestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lcd_request_errors_total",
+		Help:      "Total number of failed LCD requests made through ClientProxy",
+	}, []string{"endpoint"})
+
+	// ActiveSubscriptions reports the number of currently open RPC
+	// subscriptions, mirroring ClientProxy.ActiveSubscriptions().
+	ActiveSubscriptions = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_subscriptions",
+		Help:      "Number of currently open Tendermint RPC subscriptions",
+	})
+
+	// EventsQueueDepth reports how many events are currently buffered on the
+	// events queue, waiting to be picked up by a worker.
+	EventsQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "events_queue_depth",
+		Help:      "Number of events currently buffered on the events queue",
+	})
+
+	// LatestHeight reports the latest chain height known to the RPC client,
+	// to be compared against LastPersistedHeight for lag alerting.
+	LatestHeight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "latest_height",
+		Help:      "Latest block height reported by the RPC client",
+	})
+
+	// LastPersistedHeight reports the highest block height successfully
+	// persisted by a worker.
+	LastPersistedHeight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_persisted_height",
+		Help:      "Highest block height successfully persisted by a worker",
+	})
+
+	// DroppedEvents counts events evicted from a subscription's output buffer
+	// because the consumer wasn't keeping up, labeled by subscriber.
+	DroppedEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dropped_events_total",
+		Help:      "Total number of subscription events evicted because the consumer fell behind",
+	}, []string{"subscriber"})
+)
@@ -0,0 +1,18 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// StartServer starts an HTTP server exposing the registered Prometheus
+// metrics on addr (e.g. ":2112") at /metrics, matching the convention used
+// by most other Cosmos tooling. It blocks while serving, so callers run it in
+// its own goroutine; it returns the error from http.ListenAndServe, which is
+// never nil.
+func StartServer(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return http.ListenAndServe(addr, mux)
+}
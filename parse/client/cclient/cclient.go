@@ -0,0 +1,40 @@
+// Package cclient defines the minimum consensus/RPC surface that Juno needs
+// in order to parse blocks, transactions and events, so that alternate
+// consensus engines (Rollkit, CometBFT forks, Penumbra, ...) can be indexed
+// without touching the parsing core in parse/ and parse/worker.
+package cclient
+
+import (
+	"context"
+
+	tmctypes "github.com/tendermint/tendermint/rpc/core/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+)
+
+const (
+	// BackendCometBFT is the default rpc_backend, backed by a stock
+	// Tendermint/CometBFT RPC client.
+	BackendCometBFT = "cometbft"
+)
+
+// ConsensusClient is the minimum surface a consensus/RPC backend must expose
+// for Juno to index it.
+type ConsensusClient interface {
+	LatestHeight() (int64, error)
+	Block(height int64) (*tmctypes.ResultBlock, error)
+	BlockResults(height int64) (*tmctypes.ResultBlockResults, error)
+	Validators(height int64) (*tmctypes.ResultValidators, error)
+	Genesis() (*tmctypes.ResultGenesis, error)
+	Tx(hash string) (*tmctypes.ResultTx, error)
+
+	// UnconfirmedTxs returns up to limit transactions currently sitting in the
+	// mempool, not yet included in a block. There is no Tendermint event for
+	// this, so callers that want to index pending transactions have to poll
+	// it on an interval.
+	UnconfirmedTxs(limit int) ([]tmtypes.Tx, error)
+
+	SubscribeBlocks(subscriber string) (<-chan tmctypes.ResultEvent, context.CancelFunc, error)
+	SubscribeEvents(subscriber string, query string) (<-chan tmctypes.ResultEvent, context.CancelFunc, error)
+
+	Stop() error
+}
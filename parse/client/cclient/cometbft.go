@@ -0,0 +1,104 @@
+package cclient
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	tmctypes "github.com/tendermint/tendermint/rpc/core/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+)
+
+// CometBFT implements ConsensusClient on top of a stock Tendermint/CometBFT
+// RPC client. It is the default backend used by client.New.
+type CometBFT struct {
+	rpcClient rpcclient.Client
+}
+
+// NewCometBFT dials rpcNode over HTTP/websocket and returns a ConsensusClient
+// backed by it.
+func NewCometBFT(rpcNode string) (ConsensusClient, error) {
+	rpcClient, err := rpcclient.NewHTTP(rpcNode, "/websocket")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rpcClient.Start(); err != nil {
+		return nil, err
+	}
+
+	return &CometBFT{rpcClient: rpcClient}, nil
+}
+
+// LatestHeight returns the latest block height on the active chain.
+func (c *CometBFT) LatestHeight() (int64, error) {
+	status, err := c.rpcClient.Status()
+	if err != nil {
+		return -1, err
+	}
+
+	return status.SyncInfo.LatestBlockHeight, nil
+}
+
+// Block queries for a block by height.
+func (c *CometBFT) Block(height int64) (*tmctypes.ResultBlock, error) {
+	return c.rpcClient.Block(&height)
+}
+
+// BlockResults queries for the results of a block by height.
+func (c *CometBFT) BlockResults(height int64) (*tmctypes.ResultBlockResults, error) {
+	return c.rpcClient.BlockResults(&height)
+}
+
+// Validators returns all the known validators for a given block height.
+func (c *CometBFT) Validators(height int64) (*tmctypes.ResultValidators, error) {
+	return c.rpcClient.Validators(&height, 0, 1000000)
+}
+
+// Genesis returns the genesis state.
+func (c *CometBFT) Genesis() (*tmctypes.ResultGenesis, error) {
+	return c.rpcClient.Genesis()
+}
+
+// Tx queries for a transaction by hash.
+func (c *CometBFT) Tx(hash string) (*tmctypes.ResultTx, error) {
+	hashRaw, err := hex.DecodeString(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.rpcClient.Tx(hashRaw, false)
+}
+
+// UnconfirmedTxs returns up to limit transactions currently sitting in the
+// mempool.
+func (c *CometBFT) UnconfirmedTxs(limit int) ([]tmtypes.Tx, error) {
+	result, err := c.rpcClient.UnconfirmedTxs(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Txs, nil
+}
+
+// SubscribeBlocks subscribes to the new block event handler with the given
+// subscriber name.
+func (c *CometBFT) SubscribeBlocks(subscriber string) (<-chan tmctypes.ResultEvent, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	eventCh, err := c.rpcClient.Subscribe(ctx, subscriber, "tm.event = 'NewBlock'")
+	return eventCh, cancel, err
+}
+
+// SubscribeEvents subscribes to the given query with the given subscriber
+// name.
+func (c *CometBFT) SubscribeEvents(subscriber string, query string) (<-chan tmctypes.ResultEvent, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	eventCh, err := c.rpcClient.Subscribe(ctx, subscriber, query)
+	return eventCh, cancel, err
+}
+
+// Stop defers the node stop execution to the RPC client.
+func (c *CometBFT) Stop() error {
+	return c.rpcClient.Stop()
+}
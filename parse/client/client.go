@@ -2,112 +2,100 @@ package client
 
 import (
 	"context"
-	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"time"
 
 	"github.com/cosmos/cosmos-sdk/codec"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/desmos-labs/juno/config"
-	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	"github.com/desmos-labs/juno/metrics"
+	"github.com/desmos-labs/juno/parse/client/cclient"
+	"github.com/prometheus/client_golang/prometheus"
 	tmctypes "github.com/tendermint/tendermint/rpc/core/types"
 )
 
-// ClientProxy implements a wrapper around both a Tendermint RPC client and a
-// Cosmos Sdk REST client that allows for essential data queries.
-type ClientProxy struct {
-	rpcClient  rpcclient.Client // Tendermint RPC node
-	clientNode string           // Full node
-	cdc        *codec.Codec
-}
-
-func New(cfg config.Config, codec *codec.Codec) (ClientProxy, error) {
-	rpcClient, err := rpcclient.NewHTTP(cfg.RPCNode, "/websocket")
-	if err != nil {
-		return ClientProxy{}, err
-	}
+const (
+	// NodeModeFull dials the configured RPC node directly and trusts
+	// whatever it returns.
+	NodeModeFull = "full"
 
-	if err := rpcClient.Start(); err != nil {
-		return ClientProxy{}, err
-	}
+	// NodeModeLight verifies every block/header returned against a
+	// tendermint/lite2 light client before handing it to the caller.
+	NodeModeLight = "light"
+)
 
-	return ClientProxy{rpcClient: rpcClient, clientNode: cfg.ClientNode, cdc: codec}, nil
+// ClientProxy defines the behaviour that any RPC client implementation must
+// provide, regardless of whether it trusts a single full node (FullClientProxy)
+// or verifies responses against a light client (LightClientProxy). This lets
+// parse.ParseCmdHandler and the workers remain agnostic of which mode is
+// active.
+type ClientProxy interface {
+	LatestHeight() (int64, error)
+	Block(height int64) (*tmctypes.ResultBlock, error)
+	BlockResults(height int64) (*tmctypes.ResultBlockResults, error)
+	Validators(height int64) (*tmctypes.ResultValidators, error)
+	Genesis() (*tmctypes.ResultGenesis, error)
+
+	TendermintTx(hash string) (*tmctypes.ResultTx, error)
+	Tx(hash string) (sdk.TxResponse, error)
+	Txs(block *tmctypes.ResultBlock) ([]sdk.TxResponse, error)
+	QueryLCD(endpoint string, ptr interface{}) error
+
+	SubscribeNewBlocks(subscriber string) (<-chan tmctypes.ResultEvent, context.CancelFunc, error)
+	SubscribeEvents(subscriber string, query string) (<-chan tmctypes.ResultEvent, context.CancelFunc, error)
+
+	// SubscribeMempoolTxs subscribes to pending (not-yet-committed)
+	// transactions, so they can be indexed before they appear in a block.
+	SubscribeMempoolTxs(subscriber string) (<-chan tmctypes.ResultEvent, context.CancelFunc, error)
+
+	// ActiveSubscriptions reports how many subscriptions have been opened and
+	// not yet cancelled. A subscription whose channel was closed still counts
+	// until its cancel function is called.
+	ActiveSubscriptions() int
+
+	Stop() error
 }
 
-// LatestHeight returns the latest block height on the active chain. An error
-// is returned if the query fails.
-func (cp ClientProxy) LatestHeight() (int64, error) {
-	status, err := cp.rpcClient.Status()
-	if err != nil {
-		return -1, err
+// New returns the ClientProxy implementation selected by cfg.NodeMode,
+// defaulting to a FullClientProxy when the field is left empty.
+func New(cfg config.Config, codec *codec.Codec) (ClientProxy, error) {
+	switch cfg.NodeMode {
+	case NodeModeLight:
+		return newLightClientProxy(cfg, codec)
+	case NodeModeFull, "":
+		return newFullClientProxy(cfg, codec)
+	default:
+		return nil, fmt.Errorf("invalid node_mode: %s", cfg.NodeMode)
 	}
-
-	height := status.SyncInfo.LatestBlockHeight
-	return height, nil
-}
-
-// Block queries for a block by height. An error is returned if the query fails.
-func (cp ClientProxy) Block(height int64) (*tmctypes.ResultBlock, error) {
-	return cp.rpcClient.Block(&height)
 }
 
-func (cp ClientProxy) BlockResults(height int64) (*tmctypes.ResultBlockResults, error) {
-	return cp.rpcClient.BlockResults(&height)
+// restClient implements the LCD-backed parts of ClientProxy (QueryLCD, Tx,
+// Txs) that are identical regardless of how the RPC side is trusted, so
+// FullClientProxy and LightClientProxy can both embed it instead of
+// duplicating the HTTP/decoding logic.
+type restClient struct {
+	clientNode string
+	cdc        *codec.Codec
 }
 
-// TendermintTx queries for a transaction by hash. An error is returned if the
-// query fails.
-func (cp ClientProxy) TendermintTx(hash string) (*tmctypes.ResultTx, error) {
-	hashRaw, err := hex.DecodeString(hash)
-	if err != nil {
-		return nil, err
+// QueryLCD queries the LCD at the given endpoint, and deserializes the result into the given pointer.
+// If an error is raised, retuns the error. Latency and error counts are
+// reported to the metrics package so operators can alert on LCD health.
+func (rc restClient) QueryLCD(endpoint string, ptr interface{}) error {
+	timer := prometheus.NewTimer(metrics.LCDRequestDuration.WithLabelValues(endpoint))
+	defer timer.ObserveDuration()
+
+	if err := rc.queryLCD(endpoint, ptr); err != nil {
+		metrics.LCDRequestErrors.WithLabelValues(endpoint).Inc()
+		return err
 	}
 
-	return cp.rpcClient.Tx(hashRaw, false)
-}
-
-// Validators returns all the known Tendermint validators for a given block
-// height. An error is returned if the query fails.
-func (cp ClientProxy) Validators(height int64) (*tmctypes.ResultValidators, error) {
-	return cp.rpcClient.Validators(&height, 0, 1000000)
-}
-
-// Genesis returns the genesis state
-func (cp ClientProxy) Genesis() (*tmctypes.ResultGenesis, error) {
-	return cp.rpcClient.Genesis()
-}
-
-// Stop defers the node stop execution to the RPC client.
-func (cp ClientProxy) Stop() error {
-	return cp.rpcClient.Stop()
-}
-
-// SubscribeNewBlocks subscribes to the new block event handler through the RPC
-// client with the given subscriber name. An receiving only channel, context
-// cancel function and an error is returned. It is up to the caller to cancel
-// the context and handle any errors appropriately.
-func (cp ClientProxy) SubscribeNewBlocks(subscriber string) (<-chan tmctypes.ResultEvent, context.CancelFunc, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	eventCh, err := cp.rpcClient.Subscribe(ctx, subscriber, "tm.event = 'NewBlock'")
-	return eventCh, cancel, err
-}
-
-// SubscribeEvents subscribes to the new event handler based on the given query
-// through the RPC  client with the given subscriber name. An receiving only channel,
-// context  cancel function and an error is returned. It is up to the caller to cancel
-// the context and handle any errors appropriately.
-func (cp ClientProxy) SubscribeEvents(subscriber string, query string) (<-chan tmctypes.ResultEvent, context.CancelFunc, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	eventCh, err := cp.rpcClient.Subscribe(ctx, subscriber, query)
-	return eventCh, cancel, err
+	return nil
 }
 
-// QueryLCD queries the LCD at the given endpoint, and deserializes the result into the given pointer.
-// If an error is raised, retuns the error
-func (cp ClientProxy) QueryLCD(endpoint string, ptr interface{}) error {
-	resp, err := http.Get(fmt.Sprintf("%s/%s", cp.clientNode, endpoint))
+func (rc restClient) queryLCD(endpoint string, ptr interface{}) error {
+	resp, err := http.Get(fmt.Sprintf("%s/%s", rc.clientNode, endpoint))
 	if err != nil {
 		return err
 	}
@@ -119,19 +107,15 @@ func (cp ClientProxy) QueryLCD(endpoint string, ptr interface{}) error {
 		return err
 	}
 
-	if err := cp.cdc.UnmarshalJSON(bz, ptr); err != nil {
-		return err
-	}
-
-	return nil
+	return rc.cdc.UnmarshalJSON(bz, ptr)
 }
 
 // Tx queries for a transaction from the REST client and decodes it into a sdk.Tx
 // if the transaction exists. An error is returned if the tx doesn't exist or
 // decoding fails.
-func (cp ClientProxy) Tx(hash string) (sdk.TxResponse, error) {
+func (rc restClient) Tx(hash string) (sdk.TxResponse, error) {
 	var tx sdk.TxResponse
-	if err := cp.QueryLCD(fmt.Sprintf("txs/%s", hash), &tx); err != nil {
+	if err := rc.QueryLCD(fmt.Sprintf("txs/%s", hash), &tx); err != nil {
 		return sdk.TxResponse{}, err
 	}
 
@@ -141,11 +125,11 @@ func (cp ClientProxy) Tx(hash string) (sdk.TxResponse, error) {
 // Txs queries for all the transactions in a block. Transactions are returned
 // in the sdk.TxResponse format which internally contains an sdk.Tx. An error is
 // returned if any query fails.
-func (cp ClientProxy) Txs(block *tmctypes.ResultBlock) ([]sdk.TxResponse, error) {
+func (rc restClient) Txs(block *tmctypes.ResultBlock) ([]sdk.TxResponse, error) {
 	txResponses := make([]sdk.TxResponse, len(block.Block.Txs), len(block.Block.Txs))
 
 	for i, tmTx := range block.Block.Txs {
-		txResponse, err := cp.Tx(fmt.Sprintf("%X", tmTx.Hash()))
+		txResponse, err := rc.Tx(fmt.Sprintf("%X", tmTx.Hash()))
 		if err != nil {
 			return nil, err
 		}
@@ -155,3 +139,113 @@ func (cp ClientProxy) Txs(block *tmctypes.ResultBlock) ([]sdk.TxResponse, error)
 
 	return txResponses, nil
 }
+
+// FullClientProxy implements ClientProxy around a pluggable cclient.ConsensusClient
+// plus a Cosmos Sdk REST client that allows for essential data queries,
+// trusting whatever node/backend it is pointed at.
+type FullClientProxy struct {
+	restClient
+	consensus cclient.ConsensusClient
+	subs      *subscriptionLimiter
+}
+
+func newFullClientProxy(cfg config.Config, codec *codec.Codec) (ClientProxy, error) {
+	consensus, err := newConsensusClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return FullClientProxy{
+		restClient: restClient{clientNode: cfg.ClientNode, cdc: codec},
+		consensus:  consensus,
+		subs:       newSubscriptionLimiter(cfg),
+	}, nil
+}
+
+// newConsensusClient dispatches on cfg.RPCBackend to build the
+// cclient.ConsensusClient implementation that backs a FullClientProxy or a
+// LightClientProxy, defaulting to a stock Tendermint/CometBFT client when left unset.
+func newConsensusClient(cfg config.Config) (cclient.ConsensusClient, error) {
+	switch cfg.RPCBackend {
+	case cclient.BackendCometBFT, "":
+		return cclient.NewCometBFT(cfg.RPCNode)
+	default:
+		return nil, fmt.Errorf("unsupported rpc_backend: %s", cfg.RPCBackend)
+	}
+}
+
+// LatestHeight returns the latest block height on the active chain. An error
+// is returned if the query fails.
+func (cp FullClientProxy) LatestHeight() (int64, error) {
+	return cp.consensus.LatestHeight()
+}
+
+// Block queries for a block by height. An error is returned if the query fails.
+func (cp FullClientProxy) Block(height int64) (*tmctypes.ResultBlock, error) {
+	return cp.consensus.Block(height)
+}
+
+func (cp FullClientProxy) BlockResults(height int64) (*tmctypes.ResultBlockResults, error) {
+	return cp.consensus.BlockResults(height)
+}
+
+// TendermintTx queries for a transaction by hash. An error is returned if the
+// query fails.
+func (cp FullClientProxy) TendermintTx(hash string) (*tmctypes.ResultTx, error) {
+	return cp.consensus.Tx(hash)
+}
+
+// Validators returns all the known Tendermint validators for a given block
+// height. An error is returned if the query fails.
+func (cp FullClientProxy) Validators(height int64) (*tmctypes.ResultValidators, error) {
+	return cp.consensus.Validators(height)
+}
+
+// Genesis returns the genesis state
+func (cp FullClientProxy) Genesis() (*tmctypes.ResultGenesis, error) {
+	return cp.consensus.Genesis()
+}
+
+// Stop defers the node stop execution to the consensus client.
+func (cp FullClientProxy) Stop() error {
+	return cp.consensus.Stop()
+}
+
+// SubscribeNewBlocks subscribes to the new block event handler through the
+// consensus client with the given subscriber name. An receiving only
+// channel, context cancel function and an error is returned. It is up to
+// the caller to cancel the context and handle any errors appropriately. The
+// subscription is subject to the configured max_subscriptions /
+// max_queries_per_subscriber caps and its buffer is capped at
+// subscription_buffer_size.
+func (cp FullClientProxy) SubscribeNewBlocks(subscriber string) (<-chan tmctypes.ResultEvent, context.CancelFunc, error) {
+	return subscribeWithLimiter(cp.subs, subscriber, func() (<-chan tmctypes.ResultEvent, context.CancelFunc, error) {
+		return cp.consensus.SubscribeBlocks(subscriber)
+	})
+}
+
+// SubscribeEvents subscribes to the new event handler based on the given
+// query through the consensus client with the given subscriber name. An
+// receiving only channel, context cancel function and an error is returned.
+// It is up to the caller to cancel the context and handle any errors
+// appropriately. The subscription is subject to the configured
+// max_subscriptions / max_queries_per_subscriber caps and its buffer is
+// capped at subscription_buffer_size.
+func (cp FullClientProxy) SubscribeEvents(subscriber string, query string) (<-chan tmctypes.ResultEvent, context.CancelFunc, error) {
+	return subscribeWithLimiter(cp.subs, subscriber, func() (<-chan tmctypes.ResultEvent, context.CancelFunc, error) {
+		return cp.consensus.SubscribeEvents(subscriber, query)
+	})
+}
+
+// SubscribeMempoolTxs subscribes to pending transactions as they enter the
+// mempool, before they are included in a block. There is no Tendermint event
+// for this, so it polls the unconfirmed_txs RPC on an interval instead of
+// going through SubscribeEvents.
+func (cp FullClientProxy) SubscribeMempoolTxs(subscriber string) (<-chan tmctypes.ResultEvent, context.CancelFunc, error) {
+	return subscribeMempoolWithLimiter(cp.subs, subscriber, cp.consensus)
+}
+
+// ActiveSubscriptions returns the number of currently open subscriptions.
+func (cp FullClientProxy) ActiveSubscriptions() int {
+	return cp.subs.ActiveSubscriptions()
+}
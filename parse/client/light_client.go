@@ -0,0 +1,249 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	"github.com/desmos-labs/juno/config"
+	"github.com/desmos-labs/juno/parse/client/cclient"
+	dbm "github.com/tendermint/tendermint/libs/db"
+	lite "github.com/tendermint/tendermint/lite2"
+	liteprovider "github.com/tendermint/tendermint/lite2/provider"
+	litehttp "github.com/tendermint/tendermint/lite2/provider/http"
+	litedb "github.com/tendermint/tendermint/lite2/store/db"
+	tmctypes "github.com/tendermint/tendermint/rpc/core/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+)
+
+// defaultTrustingPeriod bounds how long a trusted header stays trusted
+// without being refreshed. It mirrors the default used by the Tendermint
+// light client command.
+const defaultTrustingPeriod = 168 * time.Hour
+
+// LightClientProxy implements ClientProxy on top of tendermint/lite2: blocks,
+// block results and validators are verified against a trusted header before
+// being handed back to the caller, so operators don't have to fully trust
+// cfg.RPCNode.
+//
+// The trust store backing lc is an in-memory dbm.MemDB, so a restart starts
+// over from cfg.TrustedHeight/cfg.TrustedHash rather than resuming from
+// whatever header was last trusted. That's a deliberate tradeoff, not an
+// oversight: it keeps the trusted root of trust pinned to the operator's
+// config instead of a mutable on-disk state a compromised/restarted process
+// could accumulate drift in, at the cost of re-verifying from scratch (one
+// primary round trip per intermediate header) on every restart. If that
+// becomes expensive in practice, swap litedb.New's backing dbm.DB for a
+// persistent one -- the lite2 client itself doesn't care.
+type LightClientProxy struct {
+	restClient
+	consensus cclient.ConsensusClient
+	lc        *lite.Client
+	subs      *subscriptionLimiter
+}
+
+func newLightClientProxy(cfg config.Config, codec *codec.Codec) (ClientProxy, error) {
+	if cfg.TrustedHeight == 0 || cfg.TrustedHash == "" {
+		return nil, fmt.Errorf("light client mode requires trusted_height and trusted_hash to be set")
+	}
+
+	trustedHash, err := hex.DecodeString(cfg.TrustedHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted_hash: %s", err)
+	}
+
+	primary, err := litehttp.New(cfg.ChainID, cfg.RPCNode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create light client primary provider: %s", err)
+	}
+
+	witnesses := make([]liteprovider.Provider, len(cfg.Witnesses))
+	for i, w := range cfg.Witnesses {
+		witness, err := litehttp.New(cfg.ChainID, w)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create light client witness provider for %s: %s", w, err)
+		}
+		witnesses[i] = witness
+	}
+
+	lc, err := lite.NewClient(
+		cfg.ChainID,
+		lite.TrustOptions{Period: defaultTrustingPeriod, Height: cfg.TrustedHeight, Hash: trustedHash},
+		primary,
+		witnesses,
+		litedb.New(dbm.NewMemDB(), ""),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	consensus, err := newConsensusClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return LightClientProxy{
+		restClient: restClient{clientNode: cfg.ClientNode, cdc: codec},
+		consensus:  consensus,
+		lc:         lc,
+		subs:       newSubscriptionLimiter(cfg),
+	}, nil
+}
+
+// LatestHeight is a write, not just a getter: it calls cp.lc.Update, which
+// drives the light client forward by fetching and verifying the primary
+// provider's latest header. ClientProxy.LatestHeight is called on every
+// missing-block scan and flush pass precisely because advancing the trusted
+// header is the only way this proxy learns about new chain height, so the
+// side effect is required, not incidental -- callers should just be aware
+// that, unlike FullClientProxy.LatestHeight, this one does real work and can
+// fail.
+func (cp LightClientProxy) LatestHeight() (int64, error) {
+	header, err := cp.lc.Update(time.Now())
+	if err != nil {
+		return -1, err
+	}
+
+	if header == nil {
+		return cp.lc.LastTrustedHeight()
+	}
+
+	return header.Height, nil
+}
+
+// Block queries for a block by height from the (untrusted) consensus client
+// and rejects it unless its header hash matches the light client's trusted
+// header for that height.
+func (cp LightClientProxy) Block(height int64) (*tmctypes.ResultBlock, error) {
+	trusted, err := cp.verifiedHeader(height)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := cp.consensus.Block(height)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(trusted.Hash(), block.Block.Header.Hash()) {
+		return nil, fmt.Errorf("block header at height %d does not match the light client's trusted header", height)
+	}
+
+	return block, nil
+}
+
+// BlockResults queries for the results of a block by height from the
+// (untrusted) consensus client and rejects them unless their hash matches
+// the LastResultsHash committed to by the following block's trusted header,
+// the same way a full Tendermint node's own ABCI results verification works.
+// This means results for the current chain tip can't be verified until the
+// next block exists.
+func (cp LightClientProxy) BlockResults(height int64) (*tmctypes.ResultBlockResults, error) {
+	if _, err := cp.verifiedHeader(height); err != nil {
+		return nil, err
+	}
+
+	results, err := cp.consensus.BlockResults(height)
+	if err != nil {
+		return nil, err
+	}
+
+	nextHeader, err := cp.verifiedHeader(height + 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify block results at height %d: %s", height, err)
+	}
+
+	resultsHash := tmtypes.NewResults(results.Results.DeliverTx).Hash()
+	if !bytes.Equal(resultsHash, nextHeader.LastResultsHash) {
+		return nil, fmt.Errorf("block results at height %d do not match the next block's trusted last_results_hash", height)
+	}
+
+	return results, nil
+}
+
+// Validators returns all the known Tendermint validators for a given block
+// height from the (untrusted) consensus client, rejecting the set unless its
+// hash matches the light client's trusted header for that height.
+func (cp LightClientProxy) Validators(height int64) (*tmctypes.ResultValidators, error) {
+	trusted, err := cp.verifiedHeader(height)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := cp.consensus.Validators(height)
+	if err != nil {
+		return nil, err
+	}
+
+	validatorSet := tmtypes.NewValidatorSet(result.Validators)
+	if !bytes.Equal(validatorSet.Hash(), trusted.ValidatorsHash) {
+		return nil, fmt.Errorf("validator set at height %d does not match the light client's trusted header", height)
+	}
+
+	return result, nil
+}
+
+// Genesis returns the genesis state
+func (cp LightClientProxy) Genesis() (*tmctypes.ResultGenesis, error) {
+	return cp.consensus.Genesis()
+}
+
+// TendermintTx queries for a transaction by hash. An error is returned if the
+// query fails.
+func (cp LightClientProxy) TendermintTx(hash string) (*tmctypes.ResultTx, error) {
+	return cp.consensus.Tx(hash)
+}
+
+// Stop defers the node stop execution to the consensus client.
+func (cp LightClientProxy) Stop() error {
+	return cp.consensus.Stop()
+}
+
+// SubscribeNewBlocks subscribes to the new block event handler through the
+// consensus client with the given subscriber name, subject to the
+// configured subscription caps.
+func (cp LightClientProxy) SubscribeNewBlocks(subscriber string) (<-chan tmctypes.ResultEvent, context.CancelFunc, error) {
+	return subscribeWithLimiter(cp.subs, subscriber, func() (<-chan tmctypes.ResultEvent, context.CancelFunc, error) {
+		return cp.consensus.SubscribeBlocks(subscriber)
+	})
+}
+
+// SubscribeEvents subscribes to the new event handler based on the given
+// query through the consensus client with the given subscriber name,
+// subject to the configured subscription caps.
+func (cp LightClientProxy) SubscribeEvents(subscriber string, query string) (<-chan tmctypes.ResultEvent, context.CancelFunc, error) {
+	return subscribeWithLimiter(cp.subs, subscriber, func() (<-chan tmctypes.ResultEvent, context.CancelFunc, error) {
+		return cp.consensus.SubscribeEvents(subscriber, query)
+	})
+}
+
+// SubscribeMempoolTxs subscribes to pending transactions as they enter the
+// mempool, before they are included in a block. There is no Tendermint event
+// for this, so it polls the unconfirmed_txs RPC on an interval instead of
+// going through SubscribeEvents. Pending transactions are inherently
+// unconfirmed, so unlike Block/BlockResults/Validators they are not checked
+// against the light client's trusted header.
+func (cp LightClientProxy) SubscribeMempoolTxs(subscriber string) (<-chan tmctypes.ResultEvent, context.CancelFunc, error) {
+	return subscribeMempoolWithLimiter(cp.subs, subscriber, cp.consensus)
+}
+
+// ActiveSubscriptions returns the number of currently open subscriptions.
+func (cp LightClientProxy) ActiveSubscriptions() int {
+	return cp.subs.ActiveSubscriptions()
+}
+
+// verifiedHeader verifies the header at height against the light client's
+// trust chain, updating it from the primary provider first if necessary, and
+// returns the resulting trusted header so callers can cross-check it against
+// whatever the (untrusted) consensus client returns.
+func (cp LightClientProxy) verifiedHeader(height int64) (*tmtypes.SignedHeader, error) {
+	header, err := cp.lc.VerifyHeaderAtHeight(height, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("light client verification failed for height %d: %s", height, err)
+	}
+
+	return header, nil
+}
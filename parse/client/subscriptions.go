@@ -0,0 +1,246 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/desmos-labs/juno/config"
+	"github.com/desmos-labs/juno/metrics"
+	"github.com/desmos-labs/juno/parse/client/cclient"
+	"github.com/rs/zerolog/log"
+	tmctypes "github.com/tendermint/tendermint/rpc/core/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+)
+
+const (
+	// mempoolPollInterval controls how often subscribeMempoolWithLimiter polls
+	// the unconfirmed_txs RPC. Tendermint only emits a "Tx" event once a
+	// transaction is committed, so there's no websocket event to subscribe to
+	// for pending transactions; polling is the only option.
+	mempoolPollInterval = 2 * time.Second
+
+	// mempoolPollLimit caps how many unconfirmed transactions are fetched on
+	// each poll.
+	mempoolPollLimit = 100
+
+	// mempoolTxEventLabel is set as the Query field on the synthetic
+	// ResultEvent emitted for each newly-seen pending transaction, mirroring
+	// what a real subscription's query string is used for: identifying the
+	// event in logs.
+	mempoolTxEventLabel = "juno.mempool.pending_tx"
+)
+
+// subscribeFunc performs the underlying, unbounded subscription call and is
+// supplied by the caller of subscribeWithLimiter so that it stays agnostic of
+// whether the subscription is served directly by a Tendermint RPC client or
+// through a cclient.ConsensusClient.
+type subscribeFunc func() (<-chan tmctypes.ResultEvent, context.CancelFunc, error)
+
+// subscriptionLimiter caps the number of active Tendermint event
+// subscriptions a ClientProxy may hold open at once, both in total and per
+// subscriber, and tracks how many are currently active so that a stalled
+// downstream consumer no longer causes Tendermint to silently drop the
+// client. See Tendermint PR #3269.
+type subscriptionLimiter struct {
+	mtx sync.Mutex
+
+	maxSubscriptions        int
+	maxQueriesPerSubscriber int
+	bufferSize              int
+
+	bySubscriber map[string]int
+	total        int
+}
+
+func newSubscriptionLimiter(cfg config.Config) *subscriptionLimiter {
+	bufferSize := cfg.SubscriptionBufferSize
+	if bufferSize <= 0 {
+		bufferSize = 1
+	}
+
+	return &subscriptionLimiter{
+		maxSubscriptions:        cfg.MaxSubscriptions,
+		maxQueriesPerSubscriber: cfg.MaxQueriesPerSubscriber,
+		bufferSize:              bufferSize,
+		bySubscriber:            make(map[string]int),
+	}
+}
+
+// acquire reserves a subscription slot for subscriber, rejecting the request
+// once either cap has been reached.
+func (l *subscriptionLimiter) acquire(subscriber string) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.maxSubscriptions > 0 && l.total >= l.maxSubscriptions {
+		return fmt.Errorf("max_subscriptions (%d) reached, rejecting subscription for %q", l.maxSubscriptions, subscriber)
+	}
+
+	if l.maxQueriesPerSubscriber > 0 && l.bySubscriber[subscriber] >= l.maxQueriesPerSubscriber {
+		return fmt.Errorf("max_queries_per_subscriber (%d) reached for subscriber %q", l.maxQueriesPerSubscriber, subscriber)
+	}
+
+	l.bySubscriber[subscriber]++
+	l.total++
+	metrics.ActiveSubscriptions.Set(float64(l.total))
+	return nil
+}
+
+// release frees the slot reserved by a previous acquire call.
+func (l *subscriptionLimiter) release(subscriber string) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.bySubscriber[subscriber]--
+	if l.bySubscriber[subscriber] <= 0 {
+		delete(l.bySubscriber, subscriber)
+	}
+	l.total--
+	metrics.ActiveSubscriptions.Set(float64(l.total))
+}
+
+// ActiveSubscriptions returns the number of subscriptions that have been
+// acquired and not yet released, and is mirrored by
+// metrics.ActiveSubscriptions. A subscription whose channel has been closed
+// still counts until its cancel function is called.
+func (l *subscriptionLimiter) ActiveSubscriptions() int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return l.total
+}
+
+// subscribeWithLimiter runs subscribe under subs, rejecting the call if the
+// limiter is already at capacity for subscriber. The returned channel is a
+// copy of the underlying subscription capped at subs.bufferSize, and the
+// cancel function both calls the underlying subscription's cancel function
+// and releases the limiter slot.
+//
+// The copy goroutine below exits, closing outCh, only once sourceCh closes.
+// Calling cancel does not close sourceCh by itself: with the CometBFT
+// backend it only cancels the context of the subscribe request and does not
+// unsubscribe.
+//
+// When the consumer falls behind, the copy goroutine evicts the oldest
+// buffered event to make room for the new one rather than blocking on
+// `outCh <- e`. A blocking send here would just move the backpressure onto
+// sourceCh and, from there, onto the underlying subscription -- which is
+// exactly the stalled-client behaviour that got it evicted by Tendermint in
+// the first place (see PR #3269). Losing old events to make room for newer
+// ones is an acceptable tradeoff for a live feed; replayMissingBlocks/
+// runFlush exist to backfill whatever a stalled consumer dropped.
+func subscribeWithLimiter(subs *subscriptionLimiter, subscriber string, subscribe subscribeFunc) (<-chan tmctypes.ResultEvent, context.CancelFunc, error) {
+	noop := func() {}
+
+	if err := subs.acquire(subscriber); err != nil {
+		return nil, noop, err
+	}
+
+	sourceCh, cancel, err := subscribe()
+	if err != nil {
+		cancel()
+		subs.release(subscriber)
+		return nil, noop, err
+	}
+
+	outCh := make(chan tmctypes.ResultEvent, subs.bufferSize)
+	go func() {
+		defer close(outCh)
+		for e := range sourceCh {
+			select {
+			case outCh <- e:
+			default:
+				select {
+				case <-outCh:
+					metrics.DroppedEvents.WithLabelValues(subscriber).Inc()
+				default:
+				}
+				outCh <- e
+			}
+		}
+	}()
+
+	return outCh, func() {
+		cancel()
+		subs.release(subscriber)
+	}, nil
+}
+
+// PendingTxEventData is the Data payload carried by the synthetic
+// ResultEvent that subscribeMempoolWithLimiter emits for each pending
+// transaction. It's a dedicated type rather than tmtypes.EventDataTx:
+// EventDataTx represents a *committed* transaction (it carries a height and
+// an ABCI DeliverTx result), neither of which exists yet for something still
+// sitting in the mempool, and faking them would be misleading to consumers.
+type PendingTxEventData struct {
+	Tx tmtypes.Tx
+}
+
+// subscribeMempoolWithLimiter polls consensus.UnconfirmedTxs on
+// mempoolPollInterval and emits a PendingTxEventData-carrying ResultEvent for
+// every transaction hash that was not in the previous poll's result (so a
+// transaction that leaves the polled set and comes back is emitted again),
+// so callers can consume
+// pending transactions the same way they consume any other Subscribe*
+// channel. It goes through the same subscriptionLimiter as the
+// websocket-backed subscriptions, so max_subscriptions still bounds it.
+func subscribeMempoolWithLimiter(subs *subscriptionLimiter, subscriber string, consensus cclient.ConsensusClient) (<-chan tmctypes.ResultEvent, context.CancelFunc, error) {
+	noop := func() {}
+
+	if err := subs.acquire(subscriber); err != nil {
+		return nil, noop, err
+	}
+
+	outCh := make(chan tmctypes.ResultEvent, subs.bufferSize)
+	stopCh := make(chan struct{})
+
+	go func() {
+		defer close(outCh)
+
+		// seen tracks the hashes emitted for the *current* mempool contents.
+		// It's rebuilt from scratch on every poll rather than accumulated
+		// across polls, so a transaction that leaves the mempool (committed
+		// or evicted) is naturally forgotten instead of sitting in the map
+		// forever.
+		seen := make(map[string]struct{})
+		ticker := time.NewTicker(mempoolPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopCh:
+				return
+
+			case <-ticker.C:
+				txs, err := consensus.UnconfirmedTxs(mempoolPollLimit)
+				if err != nil {
+					log.Error().Err(err).Msg("failed to poll unconfirmed_txs")
+					continue
+				}
+
+				stillPending := make(map[string]struct{}, len(txs))
+				for _, tx := range txs {
+					hash := string(tx.Hash())
+					stillPending[hash] = struct{}{}
+
+					if _, ok := seen[hash]; ok {
+						continue
+					}
+
+					outCh <- tmctypes.ResultEvent{
+						Query: mempoolTxEventLabel,
+						Data:  PendingTxEventData{Tx: tx},
+					}
+				}
+				seen = stillPending
+			}
+		}
+	}()
+
+	return outCh, func() {
+		close(stopCh)
+		subs.release(subscriber)
+	}, nil
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/cosmos/cosmos-sdk/codec"
 	"github.com/desmos-labs/juno/config"
 	"github.com/desmos-labs/juno/db"
+	"github.com/desmos-labs/juno/metrics"
 	"github.com/desmos-labs/juno/parse/client"
 	"github.com/desmos-labs/juno/parse/worker"
 	"github.com/desmos-labs/juno/types"
@@ -16,6 +17,7 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -29,6 +31,16 @@ const (
 	logLevelText = "text"
 )
 
+const (
+	// blockSubscribeRetryInitialDelay is how long startNewBlockListener waits
+	// before its first retry after a failed (re-)subscribe.
+	blockSubscribeRetryInitialDelay = 1 * time.Second
+
+	// blockSubscribeRetryMaxDelay caps the exponential backoff between
+	// subscribe retries.
+	blockSubscribeRetryMaxDelay = 30 * time.Second
+)
+
 var (
 	wg sync.WaitGroup
 )
@@ -44,6 +56,8 @@ func GetParseCmd(cdc *codec.Codec, builder db.Builder) *cobra.Command {
 		},
 	}
 
+	cmd.AddCommand(GetFlushCmd(cdc, builder))
+
 	return SetupFlags(cmd)
 }
 
@@ -56,6 +70,10 @@ func SetupFlags(cmd *cobra.Command) *cobra.Command {
 	cmd.Flags().Bool(config.FlagListenEvents, true, "listen to new events")
 	cmd.Flags().String(config.FlagLogLevel, zerolog.InfoLevel.String(), "logging level")
 	cmd.Flags().String(config.FlagLogFormat, logLevelJSON, "logging format; must be either json or text")
+	cmd.Flags().Duration(config.FlagFlushInterval, 0, "if set, periodically re-enqueues any blocks missed since the last flush checkpoint instead of only scanning on startup")
+	cmd.Flags().Int64(config.FlagFlushLookback, 0, "number of blocks to look back from the last flushed height to account for reorgs")
+	cmd.Flags().String(config.FlagMetricsPort, ":2112", "address the Prometheus metrics server listens on, if metrics are enabled")
+	cmd.Flags().Bool(config.FlagListenMempool, false, "listen to and index pending mempool transactions before they are committed")
 	return cmd
 }
 
@@ -91,6 +109,17 @@ func ParseCmdHandler(codec *codec.Codec, dbBuilder db.Builder, configPath string
 		return err
 	}
 
+	// Start the Prometheus metrics server, if enabled
+	if cfg.Metrics.Enabled {
+		metricsPort := viper.GetString(config.FlagMetricsPort)
+		go func() {
+			if err := metrics.StartServer(metricsPort); err != nil {
+				log.Error().Err(err).Msg("metrics server stopped")
+			}
+		}()
+		log.Info().Str("address", metricsPort).Msg("serving Prometheus metrics")
+	}
+
 	// Init the client
 	cp, err := client.New(*cfg, codec)
 	if err != nil {
@@ -101,6 +130,9 @@ func ParseCmdHandler(codec *codec.Codec, dbBuilder db.Builder, configPath string
 	// Create a queue that will collect, aggregate and export events
 	eventsQueue := types.NewEventsQueue(25)
 
+	// Create a queue that will collect pending mempool transactions, if enabled
+	pendingTxsQueue := types.NewPendingTxsQueue(25)
+
 	database, err := dbBuilder(*cfg, codec)
 	if err != nil {
 		return errors.Wrap(err, "failed to open database connection")
@@ -110,7 +142,7 @@ func ParseCmdHandler(codec *codec.Codec, dbBuilder db.Builder, configPath string
 	workerCount := viper.GetInt64(config.FlagWorkerCount)
 	workers := make([]worker.Worker, workerCount, workerCount)
 	for i := range workers {
-		workers[i] = worker.NewWorker(codec, cp, eventsQueue, *database)
+		workers[i] = worker.NewWorker(codec, cp, eventsQueue, pendingTxsQueue, *database)
 	}
 
 	wg.Add(1)
@@ -138,6 +170,18 @@ func ParseCmdHandler(codec *codec.Codec, dbBuilder db.Builder, configPath string
 		go startNewEventsListener("tm.event = 'proposer_reward'", eventsQueue, cp)
 	}
 
+	if flushInterval := viper.GetDuration(config.FlagFlushInterval); flushInterval > 0 {
+		go startFlushRoutine(flushInterval, viper.GetInt64(config.FlagFlushLookback), eventsQueue, cp, *database)
+	}
+
+	if cfg.Metrics.Enabled {
+		go reportEventsQueueDepth(eventsQueue)
+	}
+
+	if viper.GetBool(config.FlagListenMempool) {
+		go startMempoolListener(codec, pendingTxsQueue, cp)
+	}
+
 	// Block main process (signal capture will call WaitGroup's Done)
 	wg.Wait()
 	return nil
@@ -150,6 +194,7 @@ func enqueueMissingBlocks(exportQueue types.EventsQueue, cp client.ClientProxy)
 	if err != nil {
 		log.Fatal().Err(errors.Wrap(err, "failed to get lastest block from RPC client"))
 	}
+	metrics.LatestHeight.Set(float64(latestBlockHeight))
 
 	log.Debug().Int64("latestBlockHeight", latestBlockHeight).Msg("syncing missing blocks...")
 
@@ -157,28 +202,78 @@ func enqueueMissingBlocks(exportQueue types.EventsQueue, cp client.ClientProxy)
 	for i := startHeight; i <= latestBlockHeight; i++ {
 		log.Debug().Int64("height", i).Msg("enqueueing missing block")
 		exportQueue <- i
+		metrics.BlocksEnqueued.Inc()
+	}
+}
+
+// reportEventsQueueDepth periodically reports the number of events currently
+// buffered on eventsQueue, so operators can alert on a growing backlog.
+func reportEventsQueueDepth(eventsQueue types.EventsQueue) {
+	ticker := time.NewTicker(5 * time.Second)
+	for range ticker.C {
+		metrics.EventsQueueDepth.Set(float64(len(eventsQueue)))
 	}
 }
 
 // startNewBlockListener subscribes to new block events via the Tendermint RPC
-// and enqueues each new block height onto the provided queue. It blocks as new
-// blocks are incoming.
+// and enqueues each new block height onto the provided queue. If the
+// subscription is dropped (e.g. Tendermint evicted a stalled client) or fails
+// to (re-)establish, it retries with exponential backoff instead of exiting,
+// and replays any heights missed in between once it reconnects.
 func startNewBlockListener(exportQueue types.EventsQueue, cp client.ClientProxy) {
-	eventCh, cancel, err := cp.SubscribeNewBlocks("juno-client-blocks")
-	defer cancel()
+	var lastHeight int64
+	retryDelay := blockSubscribeRetryInitialDelay
+
+	for {
+		eventCh, cancel, err := cp.SubscribeNewBlocks("juno-client-blocks")
+		if err != nil {
+			log.Error().Err(err).Dur("retry_in", retryDelay).Msg("failed to subscribe to new blocks; retrying")
+			time.Sleep(retryDelay)
+
+			retryDelay *= 2
+			if retryDelay > blockSubscribeRetryMaxDelay {
+				retryDelay = blockSubscribeRetryMaxDelay
+			}
+			continue
+		}
+		retryDelay = blockSubscribeRetryInitialDelay
+
+		log.Info().Msg("listening for new block events...")
+
+		if lastHeight > 0 {
+			replayMissingBlocks(exportQueue, cp, lastHeight)
+		}
+
+		for e := range eventCh {
+			newBlock := e.Data.(tmtypes.EventDataNewBlock).Block
+			height := newBlock.Header.Height
+
+			log.Debug().Int64("height", height).Msg("enqueueing new block")
+			exportQueue <- height
+			metrics.BlocksEnqueued.Inc()
+			lastHeight = height
+		}
+
+		cancel()
+		log.Warn().Int("active_subscriptions", cp.ActiveSubscriptions()).Msg("new block subscription dropped; re-subscribing...")
+	}
+}
 
+// replayMissingBlocks enqueues any block heights between lastHeight and the
+// current chain tip, covering the gap left by a dropped subscription so that
+// a reconnect never silently skips blocks.
+func replayMissingBlocks(exportQueue types.EventsQueue, cp client.ClientProxy, lastHeight int64) {
+	latestHeight, err := cp.LatestHeight()
 	if err != nil {
-		log.Fatal().Err(errors.Wrap(err, "failed to subscribe to new blocks"))
+		log.Error().Err(err).Msg("failed to get latest height while replaying missed blocks")
+		return
 	}
+	metrics.LatestHeight.Set(float64(latestHeight))
 
-	log.Info().Msg("listening for new block events...")
-
-	for e := range eventCh {
-		newBlock := e.Data.(tmtypes.EventDataNewBlock).Block
-		height := newBlock.Header.Height
-
-		log.Debug().Int64("height", height).Msg("enqueueing new block")
-		exportQueue <- height
+	for i := lastHeight + 1; i <= latestHeight; i++ {
+		log.Debug().Int64("height", i).Msg("replaying block missed during subscription drop")
+		exportQueue <- i
+		metrics.BlocksEnqueued.Inc()
 	}
 }
 
@@ -0,0 +1,191 @@
+package parse
+
+import (
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	"github.com/desmos-labs/juno/config"
+	"github.com/desmos-labs/juno/db"
+	"github.com/desmos-labs/juno/metrics"
+	"github.com/desmos-labs/juno/parse/client"
+	"github.com/desmos-labs/juno/parse/worker"
+	"github.com/desmos-labs/juno/types"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// GetFlushCmd returns the command that performs a single flush/backfill pass
+// against the gap between the last persisted flush checkpoint and the chain
+// tip, without starting the full parsing daemon. This lets operators run a
+// manual backfill without restarting juno.
+func GetFlushCmd(cdc *codec.Codec, builder db.Builder) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "flush [config-file]",
+		Short: "Backfill any blocks missed since the last flush checkpoint, then exit",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return FlushCmdHandler(cdc, builder, args[0])
+		},
+	}
+
+	cmd.Flags().Int64(config.FlagFlushLookback, 0, "number of blocks to look back from the last flushed height to account for reorgs")
+	return cmd
+}
+
+// flushDrainPollInterval is how often runFlush polls the database while
+// waiting for workers to persist the heights it just enqueued.
+const flushDrainPollInterval = 2 * time.Second
+
+// flushMaxDrainAttempts bounds how many times runFlush polls for persistence
+// before giving up and checkpointing whatever contiguous prefix did persist,
+// so a single permanently-failing height can't hang a one-shot flush forever.
+const flushMaxDrainAttempts = 150
+
+// FlushCmdHandler runs a single flush pass: it enqueues the gap between the
+// last persisted flush_state checkpoint (if any) and the current chain tip,
+// waits until the enqueued heights have been persisted or
+// flushMaxDrainAttempts polls have passed, and then checkpoints the
+// contiguous run of heights that did persist.
+func FlushCmdHandler(cdc *codec.Codec, dbBuilder db.Builder, configPath string) error {
+	cfg, err := config.ParseConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	cp, err := client.New(*cfg, cdc)
+	if err != nil {
+		return errors.Wrap(err, "failed to start RPC client")
+	}
+	defer cp.Stop()
+
+	database, err := dbBuilder(*cfg, cdc)
+	if err != nil {
+		return errors.Wrap(err, "failed to open database connection")
+	}
+
+	eventsQueue := types.NewEventsQueue(25)
+	pendingTxsQueue := types.NewPendingTxsQueue(25)
+
+	w := worker.NewWorker(cdc, cp, eventsQueue, pendingTxsQueue, *database)
+	go w.Start()
+
+	return runFlush(eventsQueue, cp, *database, viper.GetInt64(config.FlagFlushLookback))
+}
+
+// startFlushRoutine runs runFlush on the given interval until the process
+// exits. Because the flush checkpoint is persisted to the flush_state table
+// on every pass, the flush routine resumes from that checkpoint after a
+// restart. The startup scan in enqueueMissingBlocks is separate and still
+// starts from --start-height when parsing old blocks is enabled.
+func startFlushRoutine(interval time.Duration, lookback int64, eventsQueue types.EventsQueue, cp client.ClientProxy, database db.Database) {
+	log.Info().Dur("interval", interval).Int64("lookback", lookback).Msg("starting flush routine")
+
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		if err := runFlush(eventsQueue, cp, database, lookback); err != nil {
+			log.Error().Err(err).Msg("flush routine failed")
+		}
+	}
+}
+
+// runFlush enqueues the gap between the last flushed height (minus lookback,
+// to re-check recently-exported blocks that may have been reorged) and the
+// current chain tip, waits (up to flushMaxDrainAttempts polls) for the
+// workers to persist that gap, and then persists the new checkpoint back to
+// the DB. Checkpoint
+// advancement is driven by the contiguous run of heights actually persisted
+// starting at startHeight, not by the daemon's chain-tip high-water mark
+// (metrics.LastPersistedHeight / a naive "last block height" query would
+// both already sit at the tip if the new-block listener is running, which
+// would let SaveFlushState skip right over an unpersisted interior height).
+func runFlush(eventsQueue types.EventsQueue, cp client.ClientProxy, database db.Database, lookback int64) error {
+	latestHeight, err := cp.LatestHeight()
+	if err != nil {
+		return errors.Wrap(err, "failed to get latest block height from RPC client")
+	}
+	metrics.LatestHeight.Set(float64(latestHeight))
+
+	lastFlushed, err := database.GetLastFlushedHeight()
+	if err != nil {
+		return errors.Wrap(err, "failed to read flush_state checkpoint")
+	}
+
+	startHeight := lastFlushed - lookback
+	if startHeight < 1 {
+		startHeight = 1
+	}
+
+	if startHeight > latestHeight {
+		return nil
+	}
+
+	log.Debug().Int64("from", startHeight).Int64("to", latestHeight).Msg("flushing missing blocks")
+	for i := startHeight; i <= latestHeight; i++ {
+		enqueueFlushedBlock(eventsQueue, i)
+	}
+
+	persistedHeight, err := waitForPersistedRange(database, startHeight, latestHeight)
+	if err != nil {
+		return errors.Wrap(err, "failed to confirm flushed blocks were persisted")
+	}
+
+	if persistedHeight < startHeight {
+		// Nothing in the gap persisted yet; leave the checkpoint untouched
+		// rather than regressing it.
+		return nil
+	}
+
+	return database.SaveFlushState(persistedHeight)
+}
+
+// enqueueFlushedBlock enqueues a single height, kept as its own function so
+// the loop above reads the same way as enqueueMissingBlocks.
+func enqueueFlushedBlock(eventsQueue types.EventsQueue, height int64) {
+	log.Debug().Int64("height", height).Msg("enqueueing flushed block")
+	eventsQueue <- height
+	metrics.BlocksEnqueued.Inc()
+}
+
+// waitForPersistedRange polls database.HasBlock height by height, starting
+// at from, until it finds one that hasn't persisted yet or reaches to. It
+// returns the highest height of the unbroken run [from, x] that has actually
+// persisted, which is the only height it's safe to checkpoint: a gap further
+// up the range (e.g. height 105 persisted but 103 didn't) must not be
+// skipped over just because a later height happened to land first.
+//
+// It gives up after flushMaxDrainAttempts polls and returns whatever
+// contiguous prefix persisted by then, so a height that never persists
+// (e.g. a worker stuck retrying a bad block) can't hang a one-shot flush
+// forever.
+func waitForPersistedRange(database db.Database, from int64, to int64) (int64, error) {
+	ticker := time.NewTicker(flushDrainPollInterval)
+	defer ticker.Stop()
+
+	contiguous := from - 1
+	for attempt := 0; attempt < flushMaxDrainAttempts; attempt++ {
+		<-ticker.C
+
+		for h := contiguous + 1; h <= to; h++ {
+			persisted, err := database.HasBlock(h)
+			if err != nil {
+				return contiguous, err
+			}
+			if !persisted {
+				break
+			}
+			contiguous = h
+		}
+
+		if contiguous >= to {
+			return contiguous, nil
+		}
+	}
+
+	log.Warn().
+		Int64("persisted_through", contiguous).
+		Int64("target", to).
+		Msg("gave up waiting for flush to fully drain; checkpointing the contiguous prefix that did persist")
+	return contiguous, nil
+}
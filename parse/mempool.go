@@ -0,0 +1,44 @@
+package parse
+
+import (
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	"github.com/desmos-labs/juno/parse/client"
+	"github.com/desmos-labs/juno/types"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// startMempoolListener subscribes to pending (unconfirmed) transactions via
+// ClientProxy.SubscribeMempoolTxs (backed by polling the unconfirmed_txs RPC,
+// since Tendermint has no event for pending transactions), decodes each one
+// using cdc, and pushes it onto pendingTxsQueue so a worker can persist it
+// before it is ever committed to a block.
+func startMempoolListener(cdc *codec.Codec, pendingTxsQueue types.PendingTxsQueue, cp client.ClientProxy) {
+	eventCh, cancel, err := cp.SubscribeMempoolTxs("juno-client-mempool")
+	defer cancel()
+
+	if err != nil {
+		log.Fatal().Err(errors.Wrap(err, "failed to subscribe to mempool transactions"))
+	}
+
+	log.Info().Msg("listening for pending mempool transactions...")
+
+	for e := range eventCh {
+		pendingTx, ok := e.Data.(client.PendingTxEventData)
+		if !ok {
+			continue
+		}
+
+		var tx types.PendingTx
+		if err := cdc.UnmarshalBinaryLengthPrefixed(pendingTx.Tx, &tx.Tx); err != nil {
+			log.Error().Err(err).Msg("failed to decode pending transaction")
+			continue
+		}
+		tx.Hash = fmt.Sprintf("%X", pendingTx.Tx.Hash())
+
+		log.Debug().Str("hash", tx.Hash).Msg("enqueueing pending transaction")
+		pendingTxsQueue <- tx
+	}
+}